@@ -0,0 +1,397 @@
+package tftp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Client is a minimal TFTP client supporting the same RFC 2347/2348/2349/7440 option
+// extensions this package's Server negotiates.
+type Client struct {
+	addr string
+	opts *ClientOptions
+}
+
+// NewClient returns a Client that talks to the TFTP server listening on addr.
+func NewClient(addr string, opts ...ClientOpt) *Client {
+	c := &ClientOptions{
+		timeout: 5 * time.Second,
+		retries: 5,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return &Client{addr: addr, opts: c}
+}
+
+// requestedOptions builds the Option map to offer in the RRQ/WRQ, based on which
+// With* options the client was configured with.
+func (c *Client) requestedOptions() Option {
+	opts := Option{}
+
+	if c.opts.blockSize > 0 {
+		opts["blksize"] = strconv.Itoa(int(c.opts.blockSize))
+	}
+	if c.opts.windowSize > 0 {
+		opts["windowsize"] = strconv.Itoa(int(c.opts.windowSize))
+	}
+	if c.opts.timeout > 0 {
+		opts["timeout"] = strconv.Itoa(int(c.opts.timeout / time.Second))
+	}
+	if c.opts.tsize {
+		opts["tsize"] = "0"
+	}
+
+	return opts
+}
+
+// Get downloads filename from the server into w and returns the number of bytes
+// written.
+func (c *Client) Get(filename string, w io.Writer) (int64, error) {
+	serverAddr, err := net.ResolveUDPAddr("udp", c.addr)
+	if err != nil {
+		return 0, err
+	}
+
+	rrq := ReadReq{Filename: filename, Mode: "octet", Options: c.requestedOptions()}
+	req, err := rrq.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+
+	session, first, err := c.open(serverAddr, req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = session.Close() }()
+
+	blockSize := c.defaultBlockSize()
+
+	code, err := peekOpCode(first)
+	if err != nil {
+		return 0, err
+	}
+
+	switch code {
+	case OpErr:
+		return 0, unmarshalErr(first)
+	case OpOack:
+		var ack OAck
+		if err := ack.UnmarshalBinary(first); err != nil {
+			return 0, err
+		}
+		if v, ok := ack["blksize"]; ok {
+			if n, err := strconv.Atoi(v); err == nil {
+				blockSize = n
+			}
+		}
+		if err := c.sendAck(session, 0); err != nil {
+			return 0, err
+		}
+		return c.receiveData(session, blockSize, w, nil)
+	case OpData:
+		return c.receiveData(session, blockSize, w, first)
+	default:
+		return 0, fmt.Errorf("unexpected opcode %d from server", code)
+	}
+}
+
+// Put uploads the content of r to the server as filename and returns the number of
+// bytes sent.
+func (c *Client) Put(filename string, r io.Reader) (int64, error) {
+	serverAddr, err := net.ResolveUDPAddr("udp", c.addr)
+	if err != nil {
+		return 0, err
+	}
+
+	wrq := WriteReq{Filename: filename, Mode: "octet", Options: c.requestedOptions()}
+	req, err := wrq.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+
+	session, first, err := c.open(serverAddr, req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = session.Close() }()
+
+	blockSize := c.defaultBlockSize()
+
+	code, err := peekOpCode(first)
+	if err != nil {
+		return 0, err
+	}
+
+	switch code {
+	case OpErr:
+		return 0, unmarshalErr(first)
+	case OpOack:
+		var ack OAck
+		if err := ack.UnmarshalBinary(first); err != nil {
+			return 0, err
+		}
+		if v, ok := ack["blksize"]; ok {
+			if n, err := strconv.Atoi(v); err == nil {
+				blockSize = n
+			}
+		}
+	case OpAck:
+		var ack Ack
+		if err := ack.UnmarshalBinary(first); err != nil {
+			return 0, err
+		}
+		if uint16(ack) != 0 {
+			return 0, fmt.Errorf("unexpected ack for block %d, wanted 0", uint16(ack))
+		}
+	default:
+		return 0, fmt.Errorf("unexpected opcode %d from server", code)
+	}
+
+	return c.sendData(session, blockSize, r)
+}
+
+func (c *Client) defaultBlockSize() int {
+	if c.opts.blockSize > 0 {
+		return int(c.opts.blockSize)
+	}
+	return BlockSize
+}
+
+// open sends req to addr from a fresh ephemeral socket (this transfer's local TID),
+// retrying on timeout up to the configured retry count, and returns a UDP session
+// pinned to whichever remote port actually replied - the remote TID for the rest of
+// the transfer - along with that first reply's raw bytes. A reply from an unexpected
+// address gets an ErrUnknownID back and is otherwise ignored, since it isn't this
+// transfer's server.
+func (c *Client) open(addr *net.UDPAddr, req []byte) (*net.UDPConn, []byte, error) {
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buf := make([]byte, c.defaultBlockSize()+4)
+
+	for attempt := 0; attempt <= c.opts.retries; attempt++ {
+		if err := conn.SetDeadline(time.Now().Add(c.opts.timeout)); err != nil {
+			_ = conn.Close()
+			return nil, nil, err
+		}
+		if _, err := conn.WriteToUDP(req, addr); err != nil {
+			_ = conn.Close()
+			return nil, nil, err
+		}
+
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				if isTimeout(err) {
+					break // resend the request
+				}
+				_ = conn.Close()
+				return nil, nil, err
+			}
+
+			if !from.IP.Equal(addr.IP) {
+				e := Err{Error: ErrUnknownID, Message: "unexpected TID"}
+				if b, merr := e.MarshalBinary(); merr == nil {
+					_, _ = conn.WriteToUDP(b, from)
+				}
+				continue // not our server - keep waiting for the real reply
+			}
+
+			reply := append([]byte(nil), buf[:n]...)
+
+			local, ok := conn.LocalAddr().(*net.UDPAddr)
+			if !ok {
+				_ = conn.Close()
+				return nil, nil, errors.New("unexpected local address type")
+			}
+			if err := conn.Close(); err != nil {
+				return nil, nil, err
+			}
+
+			session, err := net.DialUDP("udp", local, from)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			return session, reply, nil
+		}
+	}
+
+	_ = conn.Close()
+	return nil, nil, fmt.Errorf("no response from %s after %d attempts", addr, c.opts.retries+1)
+}
+
+// receiveData reads DATA blocks from session into w, ACKing each in turn, until a
+// short block marks the end of the transfer. first, if non-nil, is a DATA packet
+// already read as part of option-less negotiation and is consumed before reading any
+// more off the wire.
+func (c *Client) receiveData(session *net.UDPConn, blockSize int, w io.Writer, first []byte) (int64, error) {
+	var total int64
+	var lastBlock uint16
+	buf := make([]byte, blockSize+4)
+
+	next := func() ([]byte, error) {
+		if first != nil {
+			p := first
+			first = nil
+			return p, nil
+		}
+
+		for attempt := 0; attempt <= c.opts.retries; attempt++ {
+			if err := session.SetDeadline(time.Now().Add(c.opts.timeout)); err != nil {
+				return nil, err
+			}
+			n, err := session.Read(buf)
+			if err == nil {
+				return buf[:n], nil
+			}
+			if !isTimeout(err) {
+				return nil, err
+			}
+			// the DATA (or our ACK) was lost - re-ack what we already have to prompt a resend
+			if err := c.sendAck(session, lastBlock); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, fmt.Errorf("timed out waiting for block %d", lastBlock+1)
+	}
+
+	for {
+		p, err := next()
+		if err != nil {
+			return total, err
+		}
+
+		data := Data{BlockSize: blockSize}
+		if err := data.UnmarshalBinary(p); err != nil {
+			return total, err
+		}
+
+		if data.Block != lastBlock+1 {
+			// duplicate or out-of-order block - re-ack what we've already got and retry
+			if err := c.sendAck(session, lastBlock); err != nil {
+				return total, err
+			}
+			continue
+		}
+
+		payload, err := io.ReadAll(data.Payload)
+		if err != nil {
+			return total, err
+		}
+
+		n, err := w.Write(payload)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+
+		lastBlock = data.Block
+		if err := c.sendAck(session, lastBlock); err != nil {
+			return total, err
+		}
+
+		if len(payload) < blockSize {
+			return total, nil
+		}
+	}
+}
+
+// sendData uploads r to session one block at a time, waiting for each block's ACK
+// before sending the next, until a short block signals the end of the transfer.
+func (c *Client) sendData(session *net.UDPConn, blockSize int, r io.Reader) (int64, error) {
+	var total int64
+	var block uint16
+	buf := make([]byte, blockSize)
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return total, err
+		}
+
+		block++
+		data := Data{Block: block - 1, BlockSize: blockSize, Payload: bytes.NewReader(buf[:n])}
+		packet, err := data.MarshalBinary()
+		if err != nil {
+			return total, err
+		}
+
+		if err := c.sendAndAwaitAck(session, packet, block); err != nil {
+			return total, err
+		}
+		total += int64(n)
+
+		if n < blockSize {
+			return total, nil
+		}
+	}
+}
+
+func (c *Client) sendAndAwaitAck(session *net.UDPConn, packet []byte, block uint16) error {
+	ackBuf := make([]byte, 4)
+
+	for attempt := 0; attempt <= c.opts.retries; attempt++ {
+		if err := session.SetDeadline(time.Now().Add(c.opts.timeout)); err != nil {
+			return err
+		}
+		if _, err := session.Write(packet); err != nil {
+			return err
+		}
+
+		n, err := session.Read(ackBuf)
+		if err != nil {
+			if isTimeout(err) {
+				continue
+			}
+			return err
+		}
+
+		var ack Ack
+		if err := ack.UnmarshalBinary(ackBuf[:n]); err != nil {
+			return err
+		}
+		if uint16(ack) == block {
+			return nil
+		}
+		// stale ack for an earlier block - keep waiting for ours
+	}
+
+	return fmt.Errorf("timed out waiting for ack of block %d", block)
+}
+
+func (c *Client) sendAck(conn *net.UDPConn, block uint16) error {
+	ack := Ack(block)
+	b, err := ack.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(b)
+	return err
+}
+
+func peekOpCode(p []byte) (OpCode, error) {
+	if len(p) < 2 {
+		return 0, errors.New("short packet")
+	}
+	return OpCode(binary.BigEndian.Uint16(p[:2])), nil
+}
+
+func unmarshalErr(p []byte) error {
+	var e Err
+	if err := e.UnmarshalBinary(p); err != nil {
+		return err
+	}
+	return fmt.Errorf("tftp error %d: %s", e.Error, e.Message)
+}
@@ -0,0 +1,54 @@
+package tftp
+
+import "time"
+
+type ClientOptions struct {
+	timeout    time.Duration
+	retries    int
+	blockSize  uint16
+	windowSize uint16
+	tsize      bool
+}
+
+type ClientOpt = func(c *ClientOptions)
+
+// WithClientTimeout sets how long the client waits for a reply before retrying.
+// Collides in name with the server's WithTimeout, hence the Client prefix.
+func WithClientTimeout(timeout time.Duration) ClientOpt {
+	return func(c *ClientOptions) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries caps how many times the client retransmits a request or block before
+// giving up on the transfer.
+func WithRetries(n int) ClientOpt {
+	return func(c *ClientOptions) {
+		c.retries = n
+	}
+}
+
+// WithBlockSize requests the RFC 2348 blksize option, letting the server agree to a
+// larger per-packet payload than the RFC 1350 default of 512 bytes.
+func WithBlockSize(size uint16) ClientOpt {
+	return func(c *ClientOptions) {
+		c.blockSize = size
+	}
+}
+
+// WithClientWindowSize requests the RFC 7440 windowsize option, letting up to that many
+// DATA packets be sent before an ACK is required. Collides in name with the server's
+// WithWindowSize, hence the Client prefix.
+func WithClientWindowSize(size uint16) ClientOpt {
+	return func(c *ClientOptions) {
+		c.windowSize = size
+	}
+}
+
+// WithTSize requests the RFC 2349 tsize option, asking the server to report (for a Get)
+// or confirm (for a Put) the transfer size during option negotiation.
+func WithTSize() ClientOpt {
+	return func(c *ClientOptions) {
+		c.tsize = true
+	}
+}
@@ -0,0 +1,140 @@
+package tftp
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Filesystem abstracts where a Server's RRQ/WRQ traffic reads and writes files. Setting
+// one via WithFilesystem lets the server handle transfers directly, without the caller
+// having to write its own ReadHandler/WriteHandler pair.
+type Filesystem interface {
+	// Open returns name's content for a read transfer, along with its size so tsize
+	// negotiation doesn't have to guess it.
+	Open(name string) (io.ReadCloser, int64, error)
+
+	// Create returns a destination to write name's content to for a write transfer. It
+	// must fail if name already exists.
+	Create(name string) (io.WriteCloser, error)
+
+	// Stat reports name's size without opening it.
+	Stat(name string) (int64, error)
+}
+
+// errPathEscapesRoot is wrapped by dirFS whenever a requested name resolves - directly or
+// via a symlink - outside of its root.
+var errPathEscapesRoot = errors.New("path escapes filesystem root")
+
+// dirFS is the default Filesystem, serving files from root and rejecting any name that
+// escapes it so a traversal attempt in an RRQ/WRQ filename can't read or write outside
+// root.
+type dirFS struct {
+	root string
+}
+
+// DirFS returns a Filesystem jailed to root. root is resolved to an absolute,
+// symlink-free path once up front; every subsequent Open/Create/Stat is checked against
+// it, re-resolving symlinks each time so a jailed file swapped for a symlink after
+// start-up is still caught.
+func DirFS(root string) (Filesystem, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dirFS{root: resolved}, nil
+}
+
+// resolve joins name onto the jail root and verifies the result - after resolving any
+// symlinks - still sits inside it, returning errPathEscapesRoot otherwise. name doesn't
+// have to exist yet (a WRQ creates it), in which case its parent directory is resolved
+// and checked instead.
+func (d *dirFS) resolve(name string) (string, error) {
+	joined := filepath.Join(d.root, filepath.Clean(string(filepath.Separator)+name))
+
+	resolved, err := filepath.EvalSymlinks(joined)
+	if errors.Is(err, os.ErrNotExist) {
+		parent, err := filepath.EvalSymlinks(filepath.Dir(joined))
+		if err != nil {
+			return "", err
+		}
+		resolved = filepath.Join(parent, filepath.Base(joined))
+	} else if err != nil {
+		return "", err
+	}
+
+	if resolved != d.root && !strings.HasPrefix(resolved, d.root+string(filepath.Separator)) {
+		return "", errPathEscapesRoot
+	}
+
+	return resolved, nil
+}
+
+func (d *dirFS) Open(name string) (io.ReadCloser, int64, error) {
+	path, err := d.resolve(name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, 0, err
+	}
+
+	return f, info.Size(), nil
+}
+
+func (d *dirFS) Create(name string) (io.WriteCloser, error) {
+	path, err := d.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+}
+
+func (d *dirFS) Stat(name string) (int64, error) {
+	path, err := d.resolve(name)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// fsErrorCode maps an error from a Filesystem method (or the legacy ReadHandler/
+// WriteHandler path) to the closest matching ErrCode for an error reply, falling back to
+// fallback if nothing more specific applies.
+func fsErrorCode(err error, fallback ErrCode) ErrCode {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, os.ErrExist):
+		return ErrFileExists
+	case errors.Is(err, errPathEscapesRoot):
+		return ErrAccessViolation
+	case errors.Is(err, os.ErrPermission):
+		return ErrAccessViolation
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,103 @@
+package tftp
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirFSOpenAndCreate(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "greeting.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := DirFS(root)
+	if err != nil {
+		t.Fatalf("DirFS: %v", err)
+	}
+
+	f, size, err := fs.Open("greeting.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	if size != 5 {
+		t.Fatalf("size = %d, want 5", size)
+	}
+	got, err := io.ReadAll(f)
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("read = %q, %v, want %q, nil", got, err, "hello")
+	}
+
+	w, err := fs.Create("uploaded.txt")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := w.Write([]byte("world")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	got, err = os.ReadFile(filepath.Join(root, "uploaded.txt"))
+	if err != nil || string(got) != "world" {
+		t.Fatalf("uploaded.txt = %q, %v, want %q, nil", got, err, "world")
+	}
+
+	if _, err := fs.Create("uploaded.txt"); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("recreating an existing file: err = %v, want os.ErrExist", err)
+	}
+
+	if _, _, err := fs.Open("missing.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("opening a missing file: err = %v, want os.ErrNotExist", err)
+	}
+
+	if statSize, err := fs.Stat("greeting.txt"); err != nil || statSize != 5 {
+		t.Fatalf("Stat = %d, %v, want 5, nil", statSize, err)
+	}
+}
+
+func TestDirFSRejectsTraversal(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "greeting.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(root), "sibling.txt"), []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := DirFS(root)
+	if err != nil {
+		t.Fatalf("DirFS: %v", err)
+	}
+
+	// "../sibling.txt" should clamp to root and report not-found, not actually read the
+	// file one level up.
+	if _, _, err := fs.Open("../sibling.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("traversal open: err = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestDirFSRejectsSymlinkEscape(t *testing.T) {
+	root := t.TempDir()
+	outsideDir := t.TempDir()
+	outside := filepath.Join(outsideDir, "secret.txt")
+	if err := os.WriteFile(outside, []byte("top secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := DirFS(root)
+	if err != nil {
+		t.Fatalf("DirFS: %v", err)
+	}
+
+	if _, _, err := fs.Open("escape.txt"); !errors.Is(err, errPathEscapesRoot) {
+		t.Fatalf("opening a symlink escaping root: err = %v, want errPathEscapesRoot", err)
+	}
+}
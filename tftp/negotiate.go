@@ -0,0 +1,109 @@
+package tftp
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Block size bounds from RFC 2348.
+const (
+	minBlockSize = 8
+	maxBlockSize = 65464
+)
+
+// Timeout bounds (in seconds) from RFC 2349.
+const (
+	minTimeoutSecs = 1
+	maxTimeoutSecs = 255
+)
+
+// Window size bounds from RFC 7440.
+const (
+	minWindowSize = 1
+	maxWindowSize = 65535
+)
+
+// knownOptions are the option names negotiateOptions understands; anything else in a
+// peer's RRQ/WRQ is rejected with ErrBadOptions rather than silently ignored.
+var knownOptions = map[string]struct{}{
+	"blksize":    {},
+	"timeout":    {},
+	"tsize":      {},
+	"windowsize": {},
+}
+
+// negotiatedOptions holds the per-transfer values agreed during RFC 2347 option
+// negotiation, ready to use by the transfer loop regardless of whether any options
+// were actually offered.
+type negotiatedOptions struct {
+	blockSize  int
+	timeout    time.Duration
+	tsize      int64
+	hasTSize   bool
+	windowSize int
+}
+
+// negotiateOptions walks the options a peer offered in its RRQ/WRQ and returns the
+// subset it understood paired with the values to echo back in an OACK, along with the
+// resulting per-transfer settings. fileSize/knowsFileSize let the caller supply the
+// authoritative size for a read (so tsize reports the real size rather than echoing
+// whatever the client guessed); they're ignored for options other than tsize. maxWindow
+// caps the windowsize the caller (server or client) is willing to agree to; 0 means any
+// value within the RFC 7440 bounds is acceptable.
+func negotiateOptions(opts Option, fileSize int64, knowsFileSize bool, maxWindow uint16) (negotiatedOptions, OAck, error) {
+	negotiated := negotiatedOptions{blockSize: BlockSize, windowSize: minWindowSize}
+	ack := OAck{}
+
+	for name := range opts {
+		if _, ok := knownOptions[name]; !ok {
+			return negotiatedOptions{}, nil, fmt.Errorf("unsupported option: %q", name)
+		}
+	}
+
+	if v, ok := opts["blksize"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minBlockSize || n > maxBlockSize {
+			return negotiatedOptions{}, nil, fmt.Errorf("bad blksize option: %q", v)
+		}
+		negotiated.blockSize = n
+		ack["blksize"] = strconv.Itoa(n)
+	}
+
+	if v, ok := opts["timeout"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minTimeoutSecs || n > maxTimeoutSecs {
+			return negotiatedOptions{}, nil, fmt.Errorf("bad timeout option: %q", v)
+		}
+		negotiated.timeout = time.Duration(n) * time.Second
+		ack["timeout"] = strconv.Itoa(n)
+	}
+
+	if v, ok := opts["tsize"]; ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return negotiatedOptions{}, nil, fmt.Errorf("bad tsize option: %q", v)
+		}
+		if knowsFileSize {
+			negotiated.tsize = fileSize
+		} else {
+			negotiated.tsize = n
+		}
+		negotiated.hasTSize = true
+		ack["tsize"] = strconv.FormatInt(negotiated.tsize, 10)
+	}
+
+	if v, ok := opts["windowsize"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minWindowSize || n > maxWindowSize {
+			return negotiatedOptions{}, nil, fmt.Errorf("bad windowsize option: %q", v)
+		}
+		if maxWindow > 0 && n > int(maxWindow) {
+			n = int(maxWindow)
+		}
+		negotiated.windowSize = n
+		ack["windowsize"] = strconv.Itoa(n)
+	}
+
+	return negotiated, ack, nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 )
 
@@ -20,6 +21,7 @@ const (
 	ErrUnknownID
 	ErrFileExists
 	ErrNoUser
+	ErrBadOptions // RFC 2347 - option negotiation failed
 )
 
 // ReadReq acts as the initial read request packet (RRQ) informing the server which file it would like to read
@@ -39,6 +41,33 @@ type ReadReq struct {
 // TFTP multicast. This option enables multicast file transmission mode.
 type Option map[string]string
 
+// writeOptions appends opts onto b as name/value pairs, each null-terminated, in sorted
+// key order so two calls with the same options produce identical bytes.
+func writeOptions(b *bytes.Buffer, opts Option) error {
+	names := make([]string, 0, len(opts))
+	for name := range opts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, err := b.WriteString(name); err != nil {
+			return err
+		}
+		if err := b.WriteByte(0); err != nil {
+			return err
+		}
+		if _, err := b.WriteString(opts[name]); err != nil {
+			return err
+		}
+		if err := b.WriteByte(0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // MarshalBinary won't work yet as we're only focusing on downloading
 func (q *ReadReq) MarshalBinary() ([]byte, error) {
 	mode := "octet"
@@ -54,7 +83,7 @@ func (q *ReadReq) MarshalBinary() ([]byte, error) {
 	b.Grow(capacity)
 
 	// Write Opcode
-	if err := binary.Write(b, binary.BigEndian, OpRRQ); err != nil {
+	if err := binary.Write(b, binary.BigEndian, uint16(OpRRQ)); err != nil {
 		return nil, err
 	}
 
@@ -78,10 +107,17 @@ func (q *ReadReq) MarshalBinary() ([]byte, error) {
 		return nil, err
 	}
 
+	if err := writeOptions(b, q.Options); err != nil {
+		return nil, err
+	}
+
 	return b.Bytes(), nil
 }
 
 func (q *ReadReq) UnmarshalBinary(p []byte) error {
+	if len(p) < 2 {
+		return errors.New("short RRQ")
+	}
 	s := bytes.Split(p[2:], []byte{0})
 	if len(s) < 2 {
 		return fmt.Errorf("missing filename or mode")
@@ -103,6 +139,79 @@ func (q *ReadReq) UnmarshalBinary(p []byte) error {
 	return nil
 }
 
+// WriteReq acts as the initial write request packet (WRQ) informing the server the
+// client wishes to upload a file. It's identical to ReadReq on the wire bar the opcode.
+//2 bytes     string    1 byte     string   1 byte
+//------------------------------------------------
+//| Opcode |  Filename  |   0  |    Mode    |   0  |
+//------------------------------------------------
+type WriteReq struct {
+	Filename string
+	Mode     string
+	Options  Option
+}
+
+func (q *WriteReq) MarshalBinary() ([]byte, error) {
+	mode := "octet"
+	if q.Mode != "" {
+		mode = q.Mode
+	}
+
+	capacity := 2 + 2 + len(q.Filename) + 1 + len(q.Mode) + 1
+
+	b := new(bytes.Buffer)
+	b.Grow(capacity)
+
+	if err := binary.Write(b, binary.BigEndian, uint16(OpWRQ)); err != nil {
+		return nil, err
+	}
+
+	if _, err := b.WriteString(q.Filename); err != nil {
+		return nil, err
+	}
+
+	if err := b.WriteByte(0); err != nil {
+		return nil, err
+	}
+
+	if _, err := b.WriteString(mode); err != nil {
+		return nil, err
+	}
+
+	if err := b.WriteByte(0); err != nil {
+		return nil, err
+	}
+
+	if err := writeOptions(b, q.Options); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (q *WriteReq) UnmarshalBinary(p []byte) error {
+	if len(p) < 2 {
+		return errors.New("short WRQ")
+	}
+	s := bytes.Split(p[2:], []byte{0})
+	if len(s) < 2 {
+		return fmt.Errorf("missing filename or mode")
+	}
+
+	q.Filename = string(s[0])
+	q.Mode = string(s[1])
+	if len(s) < 4 {
+		return nil
+	}
+
+	q.Options = make(Option)
+	for i := 2; i+1 < len(s); i += 2 {
+		q.Options[string(s[i])] = string(s[i+1])
+	}
+
+	return nil
+}
+
 // Data acts as the data packet that will transfer the files payload
 // 2 bytes     2 bytes      n bytes
 // ----------------------------------
@@ -112,13 +221,23 @@ type Data struct {
 	// Block enables UDP reliability by incrementing on each packet sent,
 	// the client discriminate between new packets and duplicates, sending an ack including the block number to
 	// confirm delivery
-	Block   uint16
+	Block uint16
+
+	// BlockSize is the negotiated RFC 2348 payload size for this transfer. Zero means
+	// the transfer didn't negotiate blksize, so the RFC 1350 default (BlockSize) applies.
+	BlockSize int
+
 	Payload io.Reader
 }
 
 func (d *Data) MarshalBinary() ([]byte, error) {
+	blockSize := d.BlockSize
+	if blockSize == 0 {
+		blockSize = BlockSize
+	}
+
 	b := new(bytes.Buffer)
-	b.Grow(DatagramSize)
+	b.Grow(blockSize + 4)
 
 	d.Block++
 
@@ -130,9 +249,9 @@ func (d *Data) MarshalBinary() ([]byte, error) {
 		return nil, err
 	}
 
-	// Every packet will be BlockSize (516 bytes) expect for the last one, which is how the client knows
+	// Every packet will be BlockSize bytes expect for the last one, which is how the client knows
 	// it's reached the end of the stream
-	_, err := io.CopyN(b, d.Payload, BlockSize)
+	_, err := io.CopyN(b, d.Payload, int64(blockSize))
 	if err != nil && err != io.EOF {
 		return nil, err
 	}
@@ -141,15 +260,20 @@ func (d *Data) MarshalBinary() ([]byte, error) {
 }
 
 func (d *Data) UnmarshalBinary(p []byte) error {
+	blockSize := d.BlockSize
+	if blockSize == 0 {
+		blockSize = BlockSize
+	}
+
 	// Sanity check the payload data
-	if l := len(p); l < 4 || l > DatagramSize {
+	if l := len(p); l < 4 || l > blockSize+4 {
 		return errors.New("invalid DATA")
 	}
 
-	var opcode any
+	var opcode uint16
 	// Read opcode from packet
 	err := binary.Read(bytes.NewReader(p[:2]), binary.BigEndian, &opcode)
-	if err != nil || opcode != OpData {
+	if err != nil || OpCode(opcode) != OpData {
 		return errors.New("invalid DATA")
 	}
 
@@ -179,12 +303,12 @@ func (a *Ack) MarshalBinary() ([]byte, error) {
 	b := new(bytes.Buffer)
 	b.Grow(capacity)
 
-	err := binary.Write(b, binary.BigEndian, OpAck) // Write ack op code to buffer
+	err := binary.Write(b, binary.BigEndian, uint16(OpAck)) // Write ack op code to buffer
 	if err != nil {
 		return nil, err
 	}
 
-	err = binary.Write(b, binary.BigEndian, &a) // Now write block number
+	err = binary.Write(b, binary.BigEndian, a) // Now write block number
 	if err != nil {
 		return nil, err
 	}
@@ -208,6 +332,83 @@ func (a *Ack) UnmarshalBinary(p []byte) error {
 	return binary.Read(r, binary.BigEndian, a)
 }
 
+// OAck confirms the set of options the server is prepared to honour for this transfer
+// (RFC 2347). It carries only the options the peer offered that were understood and
+// accepted, paired with the agreed-upon value.
+// 2 bytes     string    1 byte     string   1 byte
+// ------------------------------------------------
+// | Opcode |  opt1  |   0  |  value1  |   0  |  ...
+// ------------------------------------------------
+type OAck map[string]string
+
+func (o OAck) MarshalBinary() ([]byte, error) {
+	b := new(bytes.Buffer)
+
+	if err := binary.Write(b, binary.BigEndian, uint16(OpOack)); err != nil {
+		return nil, err
+	}
+
+	for name, value := range o {
+		if _, err := b.WriteString(name); err != nil {
+			return nil, err
+		}
+		if err := b.WriteByte(0); err != nil {
+			return nil, err
+		}
+		if _, err := b.WriteString(value); err != nil {
+			return nil, err
+		}
+		if err := b.WriteByte(0); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+func (o *OAck) UnmarshalBinary(p []byte) error {
+	r := bytes.NewReader(p)
+
+	var code OpCode
+	if err := binary.Read(r, binary.BigEndian, &code); err != nil {
+		return err
+	}
+
+	if code != OpOack {
+		return errors.New("invalid OACK")
+	}
+
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	opts := make(OAck)
+	if len(rest) == 0 {
+		*o = opts
+		return nil
+	}
+
+	// Every name/value is null-terminated, so the buffer must end in one; trimming it
+	// blindly (rather than just this one trailing byte) would swallow a genuine empty
+	// value, e.g. an option whose value is the empty string.
+	if rest[len(rest)-1] != 0 {
+		return errors.New("invalid OACK")
+	}
+
+	parts := bytes.Split(rest[:len(rest)-1], []byte{0})
+	if len(parts)%2 != 0 {
+		return errors.New("invalid OACK")
+	}
+
+	for i := 0; i+1 < len(parts); i += 2 {
+		opts[string(parts[i])] = string(parts[i+1])
+	}
+
+	*o = opts
+	return nil
+}
+
 // Err packet
 // 2 bytes     2 bytes       string    1 byte
 // -----------------------------------------
@@ -224,7 +425,7 @@ func (e Err) MarshalBinary() ([]byte, error) {
 	b := new(bytes.Buffer)
 	b.Grow(capacity)
 
-	err := binary.Write(b, binary.BigEndian, OpErr) // Write OpErr op code to buffer
+	err := binary.Write(b, binary.BigEndian, uint16(OpErr)) // Write OpErr op code to buffer
 	if err != nil {
 		return nil, err
 	}
@@ -246,7 +447,7 @@ func (e Err) MarshalBinary() ([]byte, error) {
 	return b.Bytes(), nil
 }
 
-func (e Err) UnmarshalBinary(p []byte) error {
+func (e *Err) UnmarshalBinary(p []byte) error {
 	r := bytes.NewBuffer(p)
 
 	var code OpCode
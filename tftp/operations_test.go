@@ -0,0 +1,220 @@
+package tftp
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Seeds below are shaped like a real tftp-hpa exchange for an octet-mode transfer of
+// boot.img: RRQ/WRQ with blksize+tsize options, a full first DATA block, its ACK, an
+// OACK echoing the negotiated options, and a "file not found" ERR.
+func FuzzReadReqRoundTrip(f *testing.F) {
+	f.Add("boot.img", "octet", "blksize", "1468")
+	f.Add("/pxelinux.cfg/default", "netascii", "", "")
+	f.Fuzz(func(t *testing.T, filename, mode, optName, optValue string) {
+		if strings.ContainsRune(filename, 0) || strings.ContainsRune(mode, 0) ||
+			strings.ContainsRune(optName, 0) || strings.ContainsRune(optValue, 0) {
+			t.Skip("embedded NUL isn't representable on the wire")
+		}
+
+		want := ReadReq{Filename: filename, Mode: mode}
+		if optName != "" {
+			want.Options = Option{optName: optValue}
+		}
+		b, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		wantMode := mode
+		if wantMode == "" {
+			wantMode = "octet" // MarshalBinary defaults a blank Mode to octet
+		}
+
+		var got ReadReq
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got.Filename != want.Filename || got.Mode != wantMode {
+			t.Fatalf("round trip mismatch: got %+v, want filename=%q mode=%q", got, want.Filename, wantMode)
+		}
+		if optName != "" && got.Options[optName] != optValue {
+			t.Fatalf("round trip dropped option: got Options=%+v, want %q=%q", got.Options, optName, optValue)
+		}
+	})
+}
+
+func FuzzWriteReqRoundTrip(f *testing.F) {
+	f.Add("boot.img", "octet", "blksize", "1468")
+	f.Add("/pxelinux.cfg/default", "netascii", "", "")
+	f.Fuzz(func(t *testing.T, filename, mode, optName, optValue string) {
+		if strings.ContainsRune(filename, 0) || strings.ContainsRune(mode, 0) ||
+			strings.ContainsRune(optName, 0) || strings.ContainsRune(optValue, 0) {
+			t.Skip("embedded NUL isn't representable on the wire")
+		}
+
+		want := WriteReq{Filename: filename, Mode: mode}
+		if optName != "" {
+			want.Options = Option{optName: optValue}
+		}
+		b, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		wantMode := mode
+		if wantMode == "" {
+			wantMode = "octet"
+		}
+
+		var got WriteReq
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got.Filename != want.Filename || got.Mode != wantMode {
+			t.Fatalf("round trip mismatch: got %+v, want filename=%q mode=%q", got, want.Filename, wantMode)
+		}
+		if optName != "" && got.Options[optName] != optValue {
+			t.Fatalf("round trip dropped option: got Options=%+v, want %q=%q", got.Options, optName, optValue)
+		}
+	})
+}
+
+func FuzzDataRoundTrip(f *testing.F) {
+	f.Add(uint16(0), []byte("the quick brown fox"))
+	f.Add(uint16(65535), []byte{})
+	f.Fuzz(func(t *testing.T, block uint16, payload []byte) {
+		if len(payload) > BlockSize {
+			payload = payload[:BlockSize]
+		}
+
+		want := &Data{Block: block, Payload: bytes.NewReader(payload)}
+		b, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		got := &Data{}
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		// MarshalBinary increments Block in place, so the wire block is block+1.
+		if got.Block != block+1 {
+			t.Fatalf("block mismatch: got %d, want %d", got.Block, block+1)
+		}
+
+		gotPayload, err := io.ReadAll(got.Payload)
+		if err != nil {
+			t.Fatalf("reading payload: %v", err)
+		}
+		if !bytes.Equal(gotPayload, payload) {
+			t.Fatalf("payload mismatch: got %q, want %q", gotPayload, payload)
+		}
+	})
+}
+
+func FuzzAckRoundTrip(f *testing.F) {
+	f.Add(uint16(0))
+	f.Add(uint16(65535))
+	f.Fuzz(func(t *testing.T, block uint16) {
+		want := Ack(block)
+		b, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		var got Ack
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got != want {
+			t.Fatalf("round trip mismatch: got %d, want %d", got, want)
+		}
+	})
+}
+
+func FuzzErrRoundTrip(f *testing.F) {
+	f.Add(uint16(ErrNotFound), "File not found")
+	f.Add(uint16(ErrAccessViolation), "")
+	f.Fuzz(func(t *testing.T, code uint16, message string) {
+		if strings.ContainsRune(message, 0) {
+			t.Skip("embedded NUL isn't representable on the wire")
+		}
+
+		want := Err{Error: ErrCode(code), Message: message}
+		b, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		var got Err
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got != want {
+			t.Fatalf("round trip mismatch: got %+v, want %+v", got, want)
+		}
+	})
+}
+
+func FuzzOAckRoundTrip(f *testing.F) {
+	f.Add("blksize", "1468")
+	f.Add("tsize", "10240000")
+	f.Fuzz(func(t *testing.T, name, value string) {
+		if name == "" || strings.ContainsRune(name, 0) || strings.ContainsRune(value, 0) {
+			t.Skip("empty or NUL-containing option isn't representable on the wire")
+		}
+
+		want := OAck{name: value}
+		b, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+
+		var got OAck
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("round trip mismatch: got %v, want %v", got, want)
+		}
+	})
+}
+
+// FuzzUnmarshalNeverPanics throws arbitrary bytes, including ones shaped like a genuine
+// tftp-hpa capture, at every packet type's Unmarshal and only cares that none of them
+// panic - malformed or truncated input should come back as an error.
+func FuzzUnmarshalNeverPanics(f *testing.F) {
+	f.Add([]byte("\x00\x01boot.img\x00octet\x00"))
+	f.Add([]byte("\x00\x01boot.img\x00octet\x00blksize\x001468\x00tsize\x000\x00"))
+	f.Add([]byte("\x00\x02boot.img\x00octet\x00"))
+	f.Add(append([]byte("\x00\x03\x00\x01"), bytes.Repeat([]byte("x"), 512)...))
+	f.Add([]byte("\x00\x04\x00\x01"))
+	f.Add([]byte("\x00\x05\x00\x01File not found\x00"))
+	f.Add([]byte("\x00\x06blksize\x001468\x00tsize\x0010240000\x00"))
+	f.Add([]byte{})
+	f.Add([]byte{0x00})
+
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		var rrq ReadReq
+		_ = rrq.UnmarshalBinary(raw)
+
+		var wrq WriteReq
+		_ = wrq.UnmarshalBinary(raw)
+
+		d := &Data{}
+		_ = d.UnmarshalBinary(raw)
+
+		var ack Ack
+		_ = ack.UnmarshalBinary(raw)
+
+		var e Err
+		_ = e.UnmarshalBinary(raw)
+
+		var oack OAck
+		_ = oack.UnmarshalBinary(raw)
+	})
+}
@@ -3,13 +3,61 @@ package tftp
 import "time"
 
 type ServerOptions struct {
-	timeout time.Duration
+	timeout      time.Duration
+	readHandler  ReadHandler
+	writeHandler WriteHandler
+	windowSize   uint16
+	maxRetries   int
+	fs           Filesystem
 }
 
-type Option = func(c *ServerOptions)
+type ServerOpt = func(c *ServerOptions)
 
-func WithTimeout(timeout time.Duration) Option {
+// WithTimeout sets the deadline for both read and write connections. Defaults to 5
+// seconds.
+func WithTimeout(timeout time.Duration) ServerOpt {
 	return func(c *ServerOptions) {
 		c.timeout = timeout
 	}
 }
+
+func WithReader(reader ReadHandler) ServerOpt {
+	return func(c *ServerOptions) {
+		c.readHandler = reader
+	}
+}
+
+func WithWriter(writer WriteHandler) ServerOpt {
+	return func(c *ServerOptions) {
+		c.writeHandler = writer
+	}
+}
+
+// WithWindowSize caps the RFC 7440 windowsize the server will agree to during option
+// negotiation, allowing up to that many DATA packets in flight before an ACK is
+// required. A client asking for a larger window is negotiated down to this value; a
+// client that doesn't offer the windowsize option at all still gets the RFC 1350
+// default of a single packet per ACK.
+func WithWindowSize(size uint16) ServerOpt {
+	return func(c *ServerOptions) {
+		c.windowSize = size
+	}
+}
+
+// WithMaxRetries caps how many times the server retransmits a DATA packet (or window),
+// an OACK, or an ACK before giving up on a transfer and sending the peer an error.
+// Defaults to 5.
+func WithMaxRetries(n int) ServerOpt {
+	return func(c *ServerOptions) {
+		c.maxRetries = n
+	}
+}
+
+// WithFilesystem serves RRQ/WRQ traffic directly against fs (such as one built with
+// DirFS) instead of requiring a ReadHandler/WriteHandler pair, and takes priority over
+// either if both are configured.
+func WithFilesystem(fs Filesystem) ServerOpt {
+	return func(c *ServerOptions) {
+		c.fs = fs
+	}
+}
@@ -3,6 +3,7 @@ package tftp
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -24,12 +25,14 @@ type OpCode uint16
 //3     Data (DATA)
 //4     Acknowledgment (ACK)
 //5     Error (ERROR)
+//6     Option Acknowledgment (OACK) - RFC 2347
 const (
 	OpRRQ = iota + 1
 	OpWRQ
 	OpData
 	OpAck
 	OpErr
+	OpOack
 )
 
 type Server struct {
@@ -40,14 +43,18 @@ type Server struct {
 	wg         sync.WaitGroup
 }
 
-// ReadHandler handles server reads
-type ReadHandler func(filename string, reader io.Reader) error
+// ReadHandler handles server reads, returning the content to send back for filename.
+// If the returned io.Reader also implements io.Closer it is closed once the transfer
+// completes.
+type ReadHandler func(filename string) (io.Reader, error)
 
-// WriteHandler handles write requests
-type WriteHandler func(writer io.Writer) error
+// WriteHandler handles write requests, returning the destination to write filename's
+// incoming content to. If the returned io.Writer also implements io.Closer it is closed
+// once the transfer completes.
+type WriteHandler func(filename string) (io.Writer, error)
 
 func NewServer(opts ...ServerOpt) *Server {
-	c := &ServerOptions{}
+	c := &ServerOptions{maxRetries: 5, timeout: 5 * time.Second}
 	for _, opt := range opts {
 		opt(c)
 	}
@@ -72,8 +79,12 @@ func (s *Server) ListenAndServer(address string) error {
 func (s *Server) Serve(conn net.PacketConn) error {
 	s.stop = make(chan struct{})
 	s.connection = conn
-	s.connection.SetDeadline(time.Now().Add(s.opts.timeout))
 
+	// Deliberately no deadline on the shared listening connection: it accepts new
+	// transfers for the server's entire lifetime. Per-transfer deadlines belong on the
+	// dialed connection each transfer gets in handlePacket, not here - a deadline on
+	// this socket would otherwise kill the whole server the first time no client
+	// happens to connect within opts.timeout.
 	for {
 		select {
 		case <-s.stop:
@@ -88,29 +99,35 @@ func (s *Server) Serve(conn net.PacketConn) error {
 
 func (s *Server) process() error {
 	buf := make([]byte, DatagramSize)
-	_, addr, err := s.connection.ReadFrom(buf)
+	n, addr, err := s.connection.ReadFrom(buf)
 	if err != nil {
 		return fmt.Errorf("reading udp packet: %v", err)
 	}
 
-	return s.handlePacket(addr, buf)
+	// A malformed datagram from one client (e.g. a short RRQ with no filename/mode) is
+	// that client's problem, not the server's - log it and keep serving everyone else
+	// rather than letting it bubble up and kill the Serve loop.
+	if err := s.handlePacket(addr, buf[:n]); err != nil {
+		log.Printf("[%s] handling packet: %v", addr, err)
+	}
+
+	return nil
 }
 
 func (s *Server) handlePacket(clientAddr net.Addr, buf []byte) error {
 	r := bytes.NewBuffer(buf)
 
 	var code OpCode
-	var err error
 
 	// Read the OpCode
-	if err = binary.Read(r, binary.BigEndian, &code); err != nil {
+	if err := binary.Read(r, binary.BigEndian, &code); err != nil {
 		return err
 	}
 
 	switch code {
 	case OpRRQ:
 		rrq := ReadReq{}
-		if err = rrq.UnmarshalBinary(buf); err != nil {
+		if err := rrq.UnmarshalBinary(buf); err != nil {
 			return err
 		}
 
@@ -123,14 +140,507 @@ func (s *Server) handlePacket(clientAddr net.Addr, buf []byte) error {
 
 		s.wg.Add(1)
 		go func() {
-			// TODO: We've read the request, now to send it back...
-			s.opts.readHandler(rrq.Filename)
+			defer s.wg.Done()
+			defer func() { _ = conn.Close() }()
+			s.serveRead(conn, rrq)
 		}()
+	case OpWRQ:
+		wrq := WriteReq{}
+		if err := wrq.UnmarshalBinary(buf); err != nil {
+			return err
+		}
+
+		log.Printf("[%s] wants to write file : %s", clientAddr, wrq.Filename)
+
+		conn, err := net.Dial("udp", clientAddr.String())
+		if err != nil {
+			return err
+		}
 
-		defer func() {
-			_ = conn.Close()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() { _ = conn.Close() }()
+			s.serveWrite(conn, wrq)
 		}()
+	default:
+		log.Printf("[%s] unsupported opcode: %d", clientAddr, code)
+	}
+
+	return nil
+}
+
+// serveRead drives a single RRQ transfer end-to-end: it negotiates any options the
+// client offered, sends an OACK when there's anything to acknowledge, and then streams
+// the file in DATA packets until the client has ACKed the final, short block.
+func (s *Server) serveRead(conn net.Conn, rrq ReadReq) {
+	reader, fileSize, knowsFileSize, err := s.openForRead(rrq.Filename)
+	if err != nil {
+		s.sendError(conn, fsErrorCode(err, ErrNotFound), err.Error())
+		return
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer func() { _ = closer.Close() }()
+	}
+
+	negotiated, ack, err := negotiateOptions(rrq.Options, fileSize, knowsFileSize, s.opts.windowSize)
+	if err != nil {
+		s.sendError(conn, ErrBadOptions, err.Error())
+		return
+	}
+
+	timeout := s.opts.timeout
+	if negotiated.timeout > 0 {
+		timeout = negotiated.timeout
+	}
+
+	if len(rrq.Options) > 0 {
+		if err := s.sendOack(conn, ack, timeout); err != nil {
+			log.Printf("[%s] sending OACK: %v", conn.RemoteAddr(), err)
+			return
+		}
+		resend := func() error { return s.sendOack(conn, ack, timeout) }
+		if err := s.awaitAckWithRetry(conn, 0, timeout, s.opts.maxRetries, resend); err != nil {
+			log.Printf("[%s] awaiting OACK confirmation: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+
+	if err := s.transmit(conn, reader, negotiated.blockSize, negotiated.windowSize, s.opts.maxRetries, timeout); err != nil {
+		log.Printf("[%s] transferring %s: %v", conn.RemoteAddr(), rrq.Filename, err)
+	}
+}
+
+// serveWrite drives a single WRQ transfer end-to-end: it negotiates any options the
+// client offered, acknowledges the request (OACK if anything was negotiated, plain
+// Ack(0) otherwise), and then receives DATA packets until the client sends a short
+// (final) block.
+func (s *Server) serveWrite(conn net.Conn, wrq WriteReq) {
+	writer, err := s.openForWrite(wrq.Filename)
+	if err != nil {
+		s.sendError(conn, fsErrorCode(err, ErrAccessViolation), err.Error())
+		return
+	}
+	if closer, ok := writer.(io.Closer); ok {
+		defer func() { _ = closer.Close() }()
+	}
+
+	negotiated, ack, err := negotiateOptions(wrq.Options, 0, false, s.opts.windowSize)
+	if err != nil {
+		s.sendError(conn, ErrBadOptions, err.Error())
+		return
+	}
+
+	timeout := s.opts.timeout
+	if negotiated.timeout > 0 {
+		timeout = negotiated.timeout
+	}
+
+	if len(wrq.Options) > 0 {
+		if err := s.sendOack(conn, ack, timeout); err != nil {
+			log.Printf("[%s] sending OACK: %v", conn.RemoteAddr(), err)
+			return
+		}
+	} else if err := s.sendAck(conn, 0, timeout); err != nil {
+		log.Printf("[%s] acking WRQ: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	if err := s.receive(conn, writer, negotiated.blockSize, s.opts.maxRetries, timeout); err != nil {
+		log.Printf("[%s] receiving %s: %v", conn.RemoteAddr(), wrq.Filename, err)
+	}
+}
+
+// openForRead resolves filename to its content for a read transfer, preferring the
+// configured Filesystem - which reports the file's size up front, enabling tsize - over
+// the legacy ReadHandler.
+func (s *Server) openForRead(filename string) (io.Reader, int64, bool, error) {
+	if s.opts.fs != nil {
+		f, size, err := s.opts.fs.Open(filename)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		return f, size, true, nil
+	}
+
+	if s.opts.readHandler == nil {
+		return nil, 0, false, errors.New("server has no filesystem or read handler configured")
+	}
+
+	reader, err := s.opts.readHandler(filename)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	size, known := readerSize(reader)
+	return reader, size, known, nil
+}
+
+// openForWrite resolves filename to a destination for a write transfer, preferring the
+// configured Filesystem over the legacy WriteHandler.
+func (s *Server) openForWrite(filename string) (io.Writer, error) {
+	if s.opts.fs != nil {
+		return s.opts.fs.Create(filename)
+	}
+
+	if s.opts.writeHandler == nil {
+		return nil, errors.New("server has no filesystem or write handler configured")
+	}
+
+	return s.opts.writeHandler(filename)
+}
+
+// receive reads DATA packets off conn and writes their payloads to w, ACKing each
+// block in turn, until a short block marks the end of the transfer. Blocks that
+// duplicate the last one written are re-ACKed rather than rewritten, so a client
+// retransmitting after a lost ACK doesn't see the transfer stall or the data doubled.
+// On timeout it re-ACKs the last block received, prompting the client to retransmit,
+// up to maxRetries times before giving up and sending the client an error.
+func (s *Server) receive(conn net.Conn, w io.Writer, blockSize, maxRetries int, timeout time.Duration) error {
+	if blockSize == 0 {
+		blockSize = BlockSize
+	}
+
+	buf := make([]byte, blockSize+4)
+	var lastWritten uint16 // block 0 was already acked by the WRQ's Ack/OACK
+	retries := 0
+
+	for {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+
+		n, err := conn.Read(buf)
+		if err != nil {
+			if isTimeout(err) {
+				retries++
+				if retries > maxRetries {
+					s.sendError(conn, ErrUnknown, "timed out waiting for data after max retries")
+					return fmt.Errorf("giving up on block %d after %d retries", lastWritten+1, maxRetries)
+				}
+				if err := s.sendAck(conn, lastWritten, timeout); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("awaiting data block %d: %w", lastWritten+1, err)
+		}
+		retries = 0
+
+		data := Data{BlockSize: blockSize}
+		if err := data.UnmarshalBinary(buf[:n]); err != nil {
+			s.sendError(conn, ErrIllegalOp, err.Error())
+			return err
+		}
+
+		if data.Block == lastWritten {
+			// duplicate of the block we've already written (the client's ACK was
+			// likely lost) - just re-ack it rather than writing it twice.
+			if err := s.sendAck(conn, data.Block, timeout); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if data.Block != lastWritten+1 {
+			return fmt.Errorf("out of order data block %d, wanted %d", data.Block, lastWritten+1)
+		}
+
+		payload, err := io.ReadAll(data.Payload)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(payload); err != nil {
+			s.sendError(conn, ErrDiskFull, err.Error())
+			return err
+		}
+
+		lastWritten = data.Block
+		if err := s.sendAck(conn, lastWritten, timeout); err != nil {
+			return err
+		}
+
+		if len(payload) < blockSize {
+			return nil // the final, short block has been written - transfer complete
+		}
+	}
+}
+
+func (s *Server) sendAck(conn net.Conn, block uint16, timeout time.Duration) error {
+	ack := Ack(block)
+
+	b, err := ack.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(b)
+	return err
+}
+
+// windowBlock is one outstanding DATA packet awaiting acknowledgment. abs is the
+// monotonically increasing 64-bit block number; unlike the 16-bit number actually
+// written to the wire, it never wraps, so the window can always tell an old ACK from a
+// new one regardless of how many blocks the transfer has sent.
+type windowBlock struct {
+	abs    uint64
+	packet []byte
+	final  bool
+}
+
+// transmit streams payload to conn as a sliding window of DATA packets (RFC 7440):
+// windowSize DATA packets are sent before an ACK is required, rather than one at a
+// time. windowSize of 1 reproduces the classic RFC 1350 send-and-wait behaviour. On
+// timeout the whole outstanding window is retransmitted, up to maxRetries times before
+// the transfer is abandoned and the peer is sent an error; a mid-window ACK rewinds and
+// resends from the next unacknowledged block, but only once per window, to avoid the
+// "sorcerer's apprentice" retransmission storm a second rewind would cause.
+func (s *Server) transmit(conn net.Conn, payload io.Reader, blockSize, windowSize, maxRetries int, timeout time.Duration) error {
+	if blockSize == 0 {
+		blockSize = BlockSize
+	}
+	if windowSize <= 0 {
+		windowSize = 1
+	}
+
+	var (
+		window []windowBlock
+		next   uint64 = 1
+		done   bool
+	)
+
+	refill := func() error {
+		for !done && len(window) < windowSize {
+			buf := make([]byte, blockSize)
+			n, err := io.ReadFull(payload, buf)
+			if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+				return err
+			}
+
+			final := n < blockSize
+			d := &Data{Block: uint16(next - 1), BlockSize: blockSize, Payload: bytes.NewReader(buf[:n])}
+			packet, err := d.MarshalBinary()
+			if err != nil {
+				return err
+			}
+
+			window = append(window, windowBlock{abs: next, packet: packet, final: final})
+			next++
+			if final {
+				done = true
+			}
+		}
+		return nil
+	}
+
+	send := func(blocks []windowBlock) error {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+		for _, b := range blocks {
+			if _, err := conn.Write(b.packet); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := refill(); err != nil {
+		return err
+	}
+
+	retries := 0
+	for len(window) > 0 {
+		if err := send(window); err != nil {
+			return fmt.Errorf("sending window starting at block %d: %w", window[0].abs, err)
+		}
+
+		rewound, timedOut := false, false
+		for len(window) > 0 && !timedOut {
+			wireAck, err := readAck(conn)
+			if err != nil {
+				if isTimeout(err) {
+					timedOut = true
+					break
+				}
+				return fmt.Errorf("awaiting ack: %w", err)
+			}
+
+			first, last := window[0].abs, window[len(window)-1].abs
+			ack := absoluteBlock(wireAck, first)
+
+			switch {
+			case ack >= last:
+				finalAcked := window[len(window)-1].final
+				window = nil
+				retries = 0
+				if finalAcked {
+					return nil
+				}
+			case ack+1 > first:
+				if rewound {
+					continue // already rewound this window; let the timeout drive the next retransmit
+				}
+				rewound = true
+				window = window[ack+1-first:]
+				if err := send(window); err != nil {
+					return fmt.Errorf("resending window from block %d: %w", window[0].abs, err)
+				}
+			default:
+				continue // stale ack for a block from an earlier window
+			}
+		}
+
+		if timedOut {
+			retries++
+			if retries > maxRetries {
+				s.sendError(conn, ErrUnknown, "timed out waiting for ack after max retries")
+				return fmt.Errorf("giving up on block %d after %d retries", window[0].abs, maxRetries)
+			}
+			continue // resend the (possibly already rewound) outstanding window
+		}
+
+		if err := refill(); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// absoluteBlock reconstructs the 64-bit absolute block number for a 16-bit wire ACK,
+// choosing whichever candidate (accounting for one wrap either way) sits closest to
+// near - the first block of the window currently being acknowledged.
+func absoluteBlock(wire uint16, near uint64) uint64 {
+	base := near &^ 0xFFFF
+	abs := base | uint64(wire)
+	switch {
+	case abs+0x8000 < near:
+		abs += 0x10000
+	case abs > near+0x8000:
+		abs -= 0x10000
+	}
+	return abs
+}
+
+func readAck(conn net.Conn) (uint16, error) {
+	buf := make([]byte, 4)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+
+	var ack Ack
+	if err := ack.UnmarshalBinary(buf[:n]); err != nil {
+		return 0, err
+	}
+	return uint16(ack), nil
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+func (s *Server) checkAck(p []byte, block uint16) error {
+	var ack Ack
+	if err := ack.UnmarshalBinary(p); err != nil {
+		return err
+	}
+	if uint16(ack) != block {
+		return fmt.Errorf("unexpected ack for block %d, wanted %d", uint16(ack), block)
+	}
+	return nil
+}
+
+func (s *Server) awaitAck(conn net.Conn, block uint16, timeout time.Duration) error {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return err
+	}
+
+	return s.checkAck(buf[:n], block)
+}
+
+// awaitAckWithRetry waits for the ack of block, calling resend and trying again up to
+// maxRetries times if none arrives within timeout, and giving up (sending the peer an
+// error) once that budget is exhausted.
+func (s *Server) awaitAckWithRetry(conn net.Conn, block uint16, timeout time.Duration, maxRetries int, resend func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := s.awaitAck(conn, block, timeout)
+		if err == nil {
+			return nil
+		}
+		if !isTimeout(err) {
+			return err
+		}
+		if attempt >= maxRetries {
+			s.sendError(conn, ErrUnknown, "timed out waiting for ack after max retries")
+			return fmt.Errorf("giving up waiting for ack of block %d after %d retries", block, maxRetries)
+		}
+		if err := resend(); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) sendOack(conn net.Conn, ack OAck, timeout time.Duration) error {
+	b, err := ack.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(b)
+	return err
+}
+
+func (s *Server) sendError(conn net.Conn, code ErrCode, message string) {
+	e := Err{Error: code, Message: message}
+
+	b, err := e.MarshalBinary()
+	if err != nil {
+		log.Printf("marshalling error packet: %v", err)
+		return
+	}
+
+	if _, err := conn.Write(b); err != nil {
+		log.Printf("[%s] sending error packet: %v", conn.RemoteAddr(), err)
+	}
+}
+
+// readerSize reports the size of r if it's cheaply knowable, so tsize negotiation can
+// hand back the real file size rather than trusting whatever the client guessed.
+func readerSize(r io.Reader) (int64, bool) {
+	if sz, ok := r.(interface{ Size() int64 }); ok {
+		return sz.Size(), true
+	}
+
+	if seeker, ok := r.(io.Seeker); ok {
+		cur, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := seeker.Seek(cur, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,184 @@
+package tftp
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startTestServer starts a Server listening on a loopback UDP port, backed by a DirFS
+// rooted at a fresh temp directory, and returns the address to dial and that directory.
+// extra options are applied after (and so can override) the short timeout/retry count
+// tests default to, which keep a dropped packet from making the suite slow.
+func startTestServer(t *testing.T, extra ...ServerOpt) (addr, root string) {
+	t.Helper()
+
+	root = t.TempDir()
+	fs, err := DirFS(root)
+	if err != nil {
+		t.Fatalf("DirFS: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	opts := append([]ServerOpt{WithFilesystem(fs), WithTimeout(200 * time.Millisecond), WithMaxRetries(2)}, extra...)
+	srv := NewServer(opts...)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = srv.Serve(conn)
+	}()
+	t.Cleanup(func() {
+		_ = conn.Close()
+		<-done
+	})
+
+	return conn.LocalAddr().String(), root
+}
+
+func TestClientServerGetPutWithOptions(t *testing.T) {
+	addr, root := startTestServer(t, WithWindowSize(4))
+
+	content := bytes.Repeat([]byte("tftp integration test payload "), 100)
+	client := NewClient(addr, WithBlockSize(1024), WithClientWindowSize(4), WithTSize())
+
+	if _, err := client.Put("upload.bin", bytes.NewReader(content)); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(root, "upload.bin"))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("uploaded content mismatch: err=%v", err)
+	}
+
+	var buf bytes.Buffer
+	n, err := client.Get("upload.bin", &buf)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if n != int64(len(content)) || !bytes.Equal(buf.Bytes(), content) {
+		t.Fatalf("downloaded content mismatch: got %d bytes, want %d", n, len(content))
+	}
+}
+
+func TestServerRejectsUnsupportedOption(t *testing.T) {
+	addr, root := startTestServer(t)
+	if err := os.WriteFile(filepath.Join(root, "exists.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	serverAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	rrq := ReadReq{Filename: "exists.txt", Mode: "octet", Options: Option{"bogus": "1"}}
+	b, err := rrq.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WriteToUDP(b, serverAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, DatagramSize)
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatalf("reading reply: %v", err)
+	}
+
+	var got Err
+	if err := got.UnmarshalBinary(buf[:n]); err != nil {
+		t.Fatalf("unmarshal err packet: %v", err)
+	}
+	if got.Error != ErrBadOptions {
+		t.Fatalf("error code = %d, want %d (ErrBadOptions)", got.Error, ErrBadOptions)
+	}
+}
+
+// TestServerRetransmitsOnDroppedAck drives a GET as a raw UDP client, deliberately
+// withholding the ACK for the first DATA block, and confirms the server's timeout fires
+// and resends that same block rather than giving up or moving on to the next one.
+func TestServerRetransmitsOnDroppedAck(t *testing.T) {
+	addr, root := startTestServer(t, WithTimeout(100*time.Millisecond), WithMaxRetries(3))
+
+	payload := bytes.Repeat([]byte("x"), BlockSize+10)
+	if err := os.WriteFile(filepath.Join(root, "retry.bin"), payload, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	serverAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	rrq := ReadReq{Filename: "retry.bin", Mode: "octet"}
+	b, err := rrq.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WriteToUDP(b, serverAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	readBlock := func() (Data, *net.UDPAddr) {
+		if err := conn.SetDeadline(time.Now().Add(2 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, DatagramSize)
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			t.Fatalf("reading DATA: %v", err)
+		}
+		var d Data
+		if err := d.UnmarshalBinary(buf[:n]); err != nil {
+			t.Fatalf("unmarshal DATA: %v", err)
+		}
+		raw, err := io.ReadAll(d.Payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Payload = bytes.NewReader(raw)
+		return d, from
+	}
+
+	first, _ := readBlock()
+	if first.Block != 1 {
+		t.Fatalf("first block = %d, want 1", first.Block)
+	}
+
+	retransmit, from := readBlock()
+	if retransmit.Block != 1 {
+		t.Fatalf("retransmitted block = %d, want 1 (same block resent after the dropped ack)", retransmit.Block)
+	}
+
+	ack := Ack(1)
+	ackBytes, err := ack.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WriteToUDP(ackBytes, from); err != nil {
+		t.Fatal(err)
+	}
+}